@@ -0,0 +1,97 @@
+package agentpb
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/consul/agent/structs"
+)
+
+// _structs.go holds the hand-written conversions between the catalog's
+// structs.* types and their agentpb wire equivalents. Like the types in
+// subscribe_types.go these aren't generated; they're maintained alongside
+// the proto messages they populate.
+
+// FromStructs populates m from arg. It returns an error if arg.Service has a
+// Kind we don't know how to represent on the wire.
+func (m *CheckServiceNode) FromStructs(arg *structs.CheckServiceNode) error {
+	if arg.Node != nil {
+		m.Node = new(Node)
+		m.Node.FromStructs(arg.Node)
+	}
+
+	if arg.Service != nil {
+		var svc NodeService
+		if err := svc.FromStructs(arg.Service); err != nil {
+			return err
+		}
+		m.Service = &svc
+	}
+
+	if len(arg.Checks) > 0 {
+		m.Checks = make([]*HealthCheck, len(arg.Checks))
+		for i, c := range arg.Checks {
+			var check HealthCheck
+			check.FromStructs(c)
+			m.Checks[i] = &check
+		}
+	}
+
+	return nil
+}
+
+// FromStructs populates m from arg.
+func (m *Node) FromStructs(arg *structs.Node) {
+	m.Node = arg.Node
+	m.Address = arg.Address
+	m.Datacenter = arg.Datacenter
+}
+
+// FromStructs populates m from arg. It returns an error if arg.Kind is a
+// service kind we don't know how to represent on the wire.
+func (m *NodeService) FromStructs(arg *structs.NodeService) error {
+	switch arg.Kind {
+	case structs.ServiceKindTypical, structs.ServiceKindConnectProxy:
+	default:
+		return fmt.Errorf("agentpb: unsupported service kind %q", arg.Kind)
+	}
+
+	m.ID = arg.ID
+	m.Service = arg.Service
+	m.Kind = arg.Kind
+	m.Tags = arg.Tags
+	m.Connect = ServiceConnect{Native: arg.Connect.Native}
+	if arg.Proxy.DestinationServiceName != "" {
+		m.Proxy = ServiceProxy{DestinationServiceName: arg.Proxy.DestinationServiceName}
+	}
+	return nil
+}
+
+// FromStructs populates m from arg.
+func (m *HealthCheck) FromStructs(arg *structs.HealthCheck) {
+	m.Node = arg.Node
+	m.CheckID = string(arg.CheckID)
+	m.Name = arg.Name
+	m.Status = arg.Status
+	m.ServiceID = arg.ServiceID
+	m.ServiceName = arg.ServiceName
+	m.Output = arg.Output
+}
+
+// FromStructs populates m from arg.
+func (m *KVSEntry) FromStructs(arg *structs.DirEntry) {
+	m.Key = arg.Key
+	m.Value = arg.Value
+	m.Flags = arg.Flags
+}
+
+// FromStructs populates m from arg. Only Kind and Name are carried: they're a
+// config entry's real catalog identity, and subscribers that need the full
+// body fetch it separately via the existing config entry RPCs.
+func (m *ConfigEntry) FromStructs(arg structs.ConfigEntry) error {
+	if arg == nil {
+		return fmt.Errorf("agentpb: nil config entry")
+	}
+	m.Kind = arg.GetKind()
+	m.Name = arg.GetName()
+	return nil
+}
@@ -0,0 +1,257 @@
+// subscribe.proto documents the wire shape of the streaming Subscribe API;
+// these types mirror it by hand rather than being protoc-gen-gogo output, so
+// they carry plain Go structs/consts with no Marshal/Unmarshal/Reset/
+// ProtoMessage methods. Keep this file and subscribe.proto in sync manually -
+// running protoc-gen-gogo against subscribe.proto would produce real
+// generated code that does not match what the FromStructs conversions in
+// structs.go expect to populate.
+
+package agentpb
+
+import "github.com/hashicorp/consul/agent/structs"
+
+// Topic enumerates the state-store streams that can be subscribed to via
+// Subscribe.
+type Topic int32
+
+const (
+	Topic_Unknown              Topic = 0
+	Topic_ServiceHealth        Topic = 1
+	Topic_ServiceHealthConnect Topic = 2
+	Topic_KV                   Topic = 3
+	Topic_Node                 Topic = 4
+	Topic_ConfigEntry          Topic = 5
+)
+
+var Topic_name = map[int32]string{
+	0: "Unknown",
+	1: "ServiceHealth",
+	2: "ServiceHealthConnect",
+	3: "KV",
+	4: "Node",
+	5: "ConfigEntry",
+}
+
+func (x Topic) String() string {
+	if name, ok := Topic_name[int32(x)]; ok {
+		return name
+	}
+	return "Unknown"
+}
+
+// CatalogOp describes the kind of mutation an Event's payload represents.
+//
+// CatalogOp_UpdateCheck must be applied as an upsert against a subscriber's
+// cached CheckServiceNode.Checks: if no check with the carried HealthCheck's
+// CheckID is already present, add it, don't drop it for not matching an
+// existing entry. A newly created service check is delivered as
+// CatalogOp_UpdateCheck the same as a mutation of an existing one, since
+// neither changes the owning ServiceHealthUpdate.CheckServiceNode.
+type CatalogOp int32
+
+const (
+	CatalogOp_Register    CatalogOp = 0
+	CatalogOp_Deregister  CatalogOp = 1
+	CatalogOp_UpdateCheck CatalogOp = 2
+	CatalogOp_DeleteCheck CatalogOp = 3
+)
+
+var CatalogOp_name = map[int32]string{
+	0: "Register",
+	1: "Deregister",
+	2: "UpdateCheck",
+	3: "DeleteCheck",
+}
+
+func (x CatalogOp) String() string {
+	if name, ok := CatalogOp_name[int32(x)]; ok {
+		return name
+	}
+	return "Register"
+}
+
+// SubscribeRequest is sent by a client to open a Subscribe stream.
+type SubscribeRequest struct {
+	Topic Topic
+	Key   string
+	// KeyPrefix treats Key as a prefix match rather than an exact match.
+	// Currently only honored by the KV topic.
+	KeyPrefix bool
+	// Kind scopes a ConfigEntry topic subscription to a single config entry
+	// kind, since a config entry's catalog identity is (Kind, Name), not Name
+	// alone. Ignored by every other topic.
+	Kind string
+	// Filter is a bexpr expression evaluated against each service instance's
+	// CheckServiceNode. ServiceHealthSnapshot and ServiceHealthConnectSnapshot
+	// leave out instances that don't match it. The state store does not apply
+	// it to the events ServiceHealthEventsFromChanges produces, since those
+	// are shared across every subscriber of a topic, and the snapshots of the
+	// other topics ignore it.
+	Filter string
+	// RequireFullRegistrations marks a ServiceHealth subscriber that can't
+	// apply UpdateCheck/DeleteCheck deltas to a cached CheckServiceNode. The
+	// state store does not read it: ServiceHealthEventsFromChanges emits the
+	// same check deltas whether or not it is set, and nothing in the state
+	// store turns a delta into a full CheckServiceNode re-registration.
+	RequireFullRegistrations bool
+	Index                    uint64
+}
+
+// Event is a single change streamed to a Subscribe caller.
+type Event struct {
+	Topic   Topic
+	Key     string
+	Index   uint64
+	Payload isEvent_Payload
+}
+
+type isEvent_Payload interface {
+	isEvent_Payload()
+}
+
+type Event_ServiceHealth struct {
+	ServiceHealth *ServiceHealthUpdate
+}
+
+type Event_KVSOp struct {
+	KVSOp *KVSOpUpdate
+}
+
+type Event_NodeOp struct {
+	NodeOp *NodeUpdate
+}
+
+type Event_ConfigEntryOp struct {
+	ConfigEntryOp *ConfigEntryUpdate
+}
+
+func (*Event_ServiceHealth) isEvent_Payload() {}
+func (*Event_KVSOp) isEvent_Payload()         {}
+func (*Event_NodeOp) isEvent_Payload()        {}
+func (*Event_ConfigEntryOp) isEvent_Payload() {}
+
+func (m *Event) GetPayload() isEvent_Payload {
+	if m != nil {
+		return m.Payload
+	}
+	return nil
+}
+
+func (m *Event) GetServiceHealth() *ServiceHealthUpdate {
+	if x, ok := m.GetPayload().(*Event_ServiceHealth); ok {
+		return x.ServiceHealth
+	}
+	return nil
+}
+
+func (m *Event) GetKVSOp() *KVSOpUpdate {
+	if x, ok := m.GetPayload().(*Event_KVSOp); ok {
+		return x.KVSOp
+	}
+	return nil
+}
+
+func (m *Event) GetNodeOp() *NodeUpdate {
+	if x, ok := m.GetPayload().(*Event_NodeOp); ok {
+		return x.NodeOp
+	}
+	return nil
+}
+
+func (m *Event) GetConfigEntryOp() *ConfigEntryUpdate {
+	if x, ok := m.GetPayload().(*Event_ConfigEntryOp); ok {
+		return x.ConfigEntryOp
+	}
+	return nil
+}
+
+// ServiceHealthUpdate is the ServiceHealth/ServiceHealthConnect topic
+// payload. Op Register/Deregister carry a full CheckServiceNode; Op
+// UpdateCheck/DeleteCheck carry only the HealthCheck that changed.
+type ServiceHealthUpdate struct {
+	Op               CatalogOp
+	CheckServiceNode *CheckServiceNode
+	Check            *HealthCheck
+}
+
+// KVSOpUpdate is the KV topic payload.
+type KVSOpUpdate struct {
+	Op    CatalogOp
+	Entry *KVSEntry
+}
+
+// NodeUpdate is the Node topic payload.
+type NodeUpdate struct {
+	Op   CatalogOp
+	Node *Node
+}
+
+// ConfigEntryUpdate is the ConfigEntry topic payload.
+type ConfigEntryUpdate struct {
+	Op    CatalogOp
+	Entry *ConfigEntry
+}
+
+// CheckServiceNode mirrors structs.CheckServiceNode for the wire.
+type CheckServiceNode struct {
+	Node    *Node
+	Service *NodeService
+	Checks  []*HealthCheck
+}
+
+// Node mirrors structs.Node for the wire.
+type Node struct {
+	Node       string
+	Address    string
+	Datacenter string
+}
+
+// NodeService mirrors structs.NodeService for the wire. Kind reuses
+// structs.ServiceKind directly (rather than a plain string) so callers can
+// keep comparing it against the structs.ServiceKind* constants without a
+// conversion at every call site.
+type NodeService struct {
+	ID      string
+	Service string
+	Kind    structs.ServiceKind
+	Tags    []string
+	Connect ServiceConnect
+	Proxy   ServiceProxy
+}
+
+// ServiceConnect mirrors structs.ServiceConnect for the wire.
+type ServiceConnect struct {
+	Native bool
+}
+
+// ServiceProxy mirrors structs.ConnectProxyConfig for the wire, carrying only
+// what subscribers need to route Connect topic events.
+type ServiceProxy struct {
+	DestinationServiceName string
+}
+
+// HealthCheck mirrors structs.HealthCheck for the wire.
+type HealthCheck struct {
+	Node        string
+	CheckID     string
+	Name        string
+	Status      string
+	ServiceID   string
+	ServiceName string
+	Output      string
+}
+
+// KVSEntry mirrors structs.DirEntry for the wire.
+type KVSEntry struct {
+	Key   string
+	Value []byte
+	Flags uint64
+}
+
+// ConfigEntry carries just enough of a structs.ConfigEntry for topic routing
+// and subscriber filtering; Kind+Name is a config entry's real identity in
+// the catalog, not Name alone.
+type ConfigEntry struct {
+	Kind string
+	Name string
+}
@@ -4,12 +4,52 @@ import (
 	"github.com/hashicorp/consul/agent/agentpb"
 	"github.com/hashicorp/consul/agent/consul/stream"
 	"github.com/hashicorp/consul/agent/structs"
+	bexpr "github.com/hashicorp/go-bexpr"
 	memdb "github.com/hashicorp/go-memdb"
 )
 
 // _events.go files contain the streaming event methods relevant to specific
 // state store components.
 
+// serviceHealthFilter wraps a compiled bexpr.Evaluator that instances are
+// checked against before being delivered to a subscriber. A nil
+// *serviceHealthFilter always matches.
+//
+// This is only ever evaluated per-subscriber against a per-req.Key result
+// set, i.e. in ServiceHealthSnapshot/ServiceHealthConnectSnapshot. It must
+// NOT be threaded into ServiceHealthEventsFromChanges or anything it calls:
+// that function runs once per commit and its output feeds the single
+// per-topic stream.EventBuffer shared by every subscriber of that topic, so
+// baking one subscriber's Filter into it would drop (or synthesize
+// deregister) events for every other subscriber of the topic too, and two
+// subscribers with different filters could never both be served correctly.
+// Applying req.Filter to the live stream is fan-out's job, at the point
+// where a subscriber's own event channel is fed from the shared buffer.
+type serviceHealthFilter struct {
+	eval *bexpr.Evaluator
+}
+
+// newServiceHealthFilter compiles expr, Consul's existing bexpr grammar used
+// by the HTTP APIs, for evaluation against structs.CheckServiceNode. An empty
+// expr is valid and yields a filter that matches everything.
+func newServiceHealthFilter(expr string) (*serviceHealthFilter, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	eval, err := bexpr.CreateEvaluator(expr, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &serviceHealthFilter{eval: eval}, nil
+}
+
+func (f *serviceHealthFilter) match(csn *structs.CheckServiceNode) (bool, error) {
+	if f == nil {
+		return true, nil
+	}
+	return f.eval.Evaluate(csn)
+}
+
 // ServiceHealthSnapshot is a stream.SnapFn that provides a streaming snapshot
 // of agentpb.Events that describe the current state of a service health query.
 func (s *Store) ServiceHealthSnapshot(req *agentpb.SubscribeRequest, buf *stream.EventBuffer) (uint64, error) {
@@ -21,9 +61,12 @@ func (s *Store) ServiceHealthSnapshot(req *agentpb.SubscribeRequest, buf *stream
 		return 0, err
 	}
 
-	checkServiceNodesToServiceHealth(idx, nodes, buf, false)
+	filter, err := newServiceHealthFilter(req.Filter)
+	if err != nil {
+		return 0, err
+	}
 
-	return idx, nil
+	return idx, checkServiceNodesToServiceHealth(idx, nodes, buf, false, filter)
 }
 
 // ServiceHealthSnapshot is a stream.SnapFn that provides a streaming snapshot
@@ -38,17 +81,20 @@ func (s *Store) ServiceHealthConnectSnapshot(req *agentpb.SubscribeRequest, buf
 		return 0, err
 	}
 
-	checkServiceNodesToServiceHealth(idx, nodes, buf, true)
-	return idx, nil
+	filter, err := newServiceHealthFilter(req.Filter)
+	if err != nil {
+		return 0, err
+	}
+
+	return idx, checkServiceNodesToServiceHealth(idx, nodes, buf, true, filter)
 }
 
 // checkServiceNodesToServiceHealth converts a list of structs.CheckServiceNodes
-// to agentpb.ServiceHealth events for streaming. If a non-nil event buffer is
-// passed, events are appended to the buffer one at a time and an nil slice is
-// returned to avoid keeping a full copy in memory.
+// to agentpb.ServiceHealth events for streaming, appending them to buf one at
+// a time to avoid keeping a full copy in memory. Nodes that don't match
+// filter are skipped entirely so they're never shipped to the subscriber.
 func checkServiceNodesToServiceHealth(idx uint64, nodes structs.CheckServiceNodes,
-	buf *stream.EventBuffer, connect bool) ([]agentpb.Event, error) {
-	var events []agentpb.Event
+	buf *stream.EventBuffer, connect bool, filter *serviceHealthFilter) error {
 	for _, n := range nodes {
 		event := agentpb.Event{
 			Index: idx,
@@ -64,9 +110,17 @@ func checkServiceNodesToServiceHealth(idx uint64, nodes structs.CheckServiceNode
 			event.Key = n.Service.Service
 		}
 
+		ok, err := filter.match(&n)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+
 		var csn agentpb.CheckServiceNode
 		if err := csn.FromStructs(&n); err != nil {
-			return nil, err
+			return err
 		}
 
 		event.Payload = &agentpb.Event_ServiceHealth{
@@ -75,13 +129,9 @@ func checkServiceNodesToServiceHealth(idx uint64, nodes structs.CheckServiceNode
 				CheckServiceNode: &csn,
 			},
 		}
-		if buf != nil {
-			buf.Append([]agentpb.Event{event})
-		} else {
-			events = append(events, event)
-		}
+		buf.Append([]agentpb.Event{event})
 	}
-	return events, nil
+	return nil
 }
 
 // serviceHealthToConnectEvents converts already formatted service health
@@ -98,6 +148,12 @@ func serviceHealthToConnectEvents(events []agentpb.Event) []agentpb.Event {
 			continue
 		}
 		node := event.GetServiceHealth().CheckServiceNode
+		if node == nil {
+			// A check-only delta carries no CheckServiceNode to judge
+			// connect-enablement from, so there's nothing to duplicate to the
+			// Connect topic.
+			continue
+		}
 		if node.Service == nil ||
 			(node.Service.Kind != structs.ServiceKindConnectProxy && !node.Service.Connect.Native) {
 			// Event is not a service instance (i.e. just a node registration)
@@ -127,11 +183,38 @@ type nodeServiceTuple struct {
 
 // ServiceHealthEventsFromChanges returns all the service and Connect health
 // events that should be emitted given a set of changes to the state store.
+// It runs once per commit and its output is appended to the single
+// per-topic stream.EventBuffer shared by every subscriber of that topic, so
+// it deliberately knows nothing about any individual subscriber's
+// SubscribeRequest: neither Filter nor RequireFullRegistrations are
+// consulted here. Both are per-subscriber preferences that a subscriber's
+// own delivery path must apply when it reads off the shared buffer: Filter
+// by evaluating the bexpr expression against the (possibly delta-derived)
+// CheckServiceNode it's tracking for that instance, and
+// RequireFullRegistrations by materializing a full CatalogOp_Register from
+// that same cached CheckServiceNode instead of forwarding a check delta
+// as-is. Applying either one here would bake one subscriber's preference
+// into the events every other subscriber of the topic also receives.
+//
+// When a transaction only mutates service-scoped checks and leaves the node
+// and service records they're attached to untouched, this emits a cheap
+// CatalogOp_UpdateCheck
+// (or CatalogOp_DeleteCheck) delta carrying just the affected structs.HealthCheck
+// instead of re-shipping the whole structs.CheckServiceNode. A node-scoped
+// check change still rebuilds every service instance on that node in full.
+// The other exception is connect-enabled instances (connect proxies and connect-native
+// apps): a bare structs.HealthCheck can't be judged for connect-enablement or
+// rekeyed to a proxy's destination service, so it can never be duplicated
+// onto the Connect topic. That's a structural limitation of the delta
+// payload, not a subscriber preference, so connect-enabled instances always
+// get a full re-registration regardless of what any subscriber asked for.
 func (s *Store) ServiceHealthEventsFromChanges(tx *txnWrapper, changes memdb.Changes) ([]agentpb.Event, error) {
 	var events []agentpb.Event
 
 	var nodeChanges map[string]*memdb.Change
 	var serviceChanges map[nodeServiceTuple]*memdb.Change
+	var checkChanges map[nodeServiceTuple][]*structs.HealthCheck
+	var checkDeletes map[nodeServiceTuple][]*structs.HealthCheck
 
 	markNode := func(node string, nodeChange *memdb.Change) {
 		if nodeChanges == nil {
@@ -164,6 +247,20 @@ func (s *Store) ServiceHealthEventsFromChanges(tx *txnWrapper, changes memdb.Cha
 			serviceChanges[k] = svcChange
 		}
 	}
+	markCheckUpdated := func(node, service string, entMeta structs.EnterpriseMeta, check *structs.HealthCheck) {
+		if checkChanges == nil {
+			checkChanges = make(map[nodeServiceTuple][]*structs.HealthCheck)
+		}
+		k := nodeServiceTuple{Node: node, ServiceID: service, EntMeta: entMeta}
+		checkChanges[k] = append(checkChanges[k], check)
+	}
+	markCheckDeleted := func(node, service string, entMeta structs.EnterpriseMeta, check *structs.HealthCheck) {
+		if checkDeletes == nil {
+			checkDeletes = make(map[nodeServiceTuple][]*structs.HealthCheck)
+		}
+		k := nodeServiceTuple{Node: node, ServiceID: service, EntMeta: entMeta}
+		checkDeletes[k] = append(checkDeletes[k], check)
+	}
 
 	for _, change := range changes {
 		switch change.Table {
@@ -205,8 +302,10 @@ func (s *Store) ServiceHealthEventsFromChanges(tx *txnWrapper, changes memdb.Cha
 					markNode(after.Node, nil)
 				} else {
 					// Check changed which means we just need to emit for the linked
-					// service.
+					// service. Record it as a check-only delta too in case the
+					// service record itself turns out to be untouched.
 					markService(after.Node, after.ServiceID, after.EnterpriseMeta, nil)
+					markCheckUpdated(after.Node, after.ServiceID, after.EnterpriseMeta, after)
 
 					// Edge case - if the check with same ID was updated to link to a
 					// different service ID but the old service with old ID still exists,
@@ -214,6 +313,7 @@ func (s *Store) ServiceHealthEventsFromChanges(tx *txnWrapper, changes memdb.Cha
 					// fewer checks now.
 					if before.ServiceID != after.ServiceID {
 						markService(before.Node, before.ServiceID, before.EnterpriseMeta, nil)
+						markCheckDeleted(before.Node, before.ServiceID, before.EnterpriseMeta, before)
 					}
 				}
 
@@ -224,6 +324,7 @@ func (s *Store) ServiceHealthEventsFromChanges(tx *txnWrapper, changes memdb.Cha
 					markNode(before.Node, nil)
 				} else {
 					markService(before.Node, before.ServiceID, before.EnterpriseMeta, nil)
+					markCheckDeleted(before.Node, before.ServiceID, before.EnterpriseMeta, before)
 				}
 
 			case change.Created():
@@ -233,6 +334,7 @@ func (s *Store) ServiceHealthEventsFromChanges(tx *txnWrapper, changes memdb.Cha
 					markNode(after.Node, nil)
 				} else {
 					markService(after.Node, after.ServiceID, after.EnterpriseMeta, nil)
+					markCheckUpdated(after.Node, after.ServiceID, after.EnterpriseMeta, after)
 				}
 			}
 		}
@@ -316,6 +418,29 @@ func (s *Store) ServiceHealthEventsFromChanges(tx *txnWrapper, changes memdb.Cha
 			// a duplicate.
 			continue
 		}
+
+		if change == nil {
+			// Nothing about the service record itself changed, only checks
+			// attached to it. A bare check delta carries no CheckServiceNode,
+			// so it can't be judged for connect-enablement or re-keyed to a
+			// proxy's destination service, meaning it can never be duplicated
+			// onto the Connect topic. Connect-enabled instances must keep
+			// getting full re-registrations so ServiceHealthConnect
+			// subscribers still see check status changes; every other
+			// instance gets the cheap delta.
+			connectEnabled, err := s.serviceIsConnectEnabled(tx, tuple.Node,
+				tuple.ServiceID, &tuple.EntMeta)
+			if err != nil {
+				return nil, err
+			}
+			if !connectEnabled {
+				es := serviceHealthCheckDeltaEvents(tx.Index,
+					checkChanges[tuple], checkDeletes[tuple])
+				events = append(events, es...)
+				continue
+			}
+		}
+
 		// Build service event and append it
 		es, err := s.serviceHealthEventsForServiceInstance(tx, tuple.Node,
 			tuple.ServiceID, &tuple.EntMeta)
@@ -329,7 +454,68 @@ func (s *Store) ServiceHealthEventsFromChanges(tx *txnWrapper, changes memdb.Cha
 	// native apps) to the relevant Connect topic.
 	events = append(events, serviceHealthToConnectEvents(events)...)
 
-	return events, nil
+	return coalesceServiceHealthEvents(events), nil
+}
+
+// coalesceServiceHealthEvents collapses the redundant events a single
+// transaction can produce for the same service instance (e.g. a service
+// update plus several check mutations on it, or the Connect duplication pass
+// above re-deriving an event that was already present) down to at most one
+// event per (Topic, Key, Node, ServiceID). Later state-based Register events
+// replace earlier ones for the same instance, but a Deregister always wins
+// over a Register since the instance no longer exists at commit time.
+// Relative ordering of first appearance is preserved so subscribers still see
+// a stable stream. UpdateCheck/DeleteCheck deltas aren't state snapshots of
+// an instance so they're passed through untouched.
+func coalesceServiceHealthEvents(events []agentpb.Event) []agentpb.Event {
+	type instanceKey struct {
+		Topic   agentpb.Topic
+		Key     string
+		Node    string
+		Service string
+	}
+
+	out := make([]agentpb.Event, 0, len(events))
+	latest := make(map[instanceKey]int)
+
+	for _, event := range events {
+		health := event.GetServiceHealth()
+		if health == nil || health.CheckServiceNode == nil {
+			out = append(out, event)
+			continue
+		}
+
+		csn := health.CheckServiceNode
+		if csn.Node == nil || csn.Service == nil {
+			// A node-only registration with no service attached (see the same
+			// guard in serviceHealthToConnectEvents): there's no ServiceID to
+			// coalesce on, so pass it through untouched rather than panicking.
+			out = append(out, event)
+			continue
+		}
+		k := instanceKey{
+			Topic:   event.Topic,
+			Key:     event.Key,
+			Node:    csn.Node.Node,
+			Service: csn.Service.ID,
+		}
+
+		i, ok := latest[k]
+		if !ok {
+			latest[k] = len(out)
+			out = append(out, event)
+			continue
+		}
+
+		if out[i].GetServiceHealth().Op == agentpb.CatalogOp_Deregister {
+			// Deregister always wins, whatever comes after it for the same
+			// instance is stale by definition.
+			continue
+		}
+		out[i] = event
+	}
+
+	return out
 }
 
 // serviceHealthEventsForNode returns health events for all services on the
@@ -404,6 +590,29 @@ func (s *Store) getNodeAndChecks(tx *txnWrapper, node string) (*structs.Node,
 	return n, nodeChecks, svcChecks, nil
 }
 
+// serviceIsConnectEnabled reports whether the given service instance is a
+// connect proxy or connect-native application, i.e. whether events about it
+// need duplicating onto the relevant Connect topic. It's used to decide
+// whether a check-only mutation is still safe to ship as a cheap delta: a
+// bare structs.HealthCheck can't be run through serviceHealthToConnectEvents,
+// so connect-enabled instances must always get the full re-registration
+// instead.
+func (s *Store) serviceIsConnectEnabled(tx *txnWrapper, node, serviceID string,
+	entMeta *structs.EnterpriseMeta) (bool, error) {
+
+	svc, err := getCompoundWithTxn(tx, "services", "id", entMeta, node, serviceID)
+	if err != nil {
+		return false, err
+	}
+	svcRaw := svc.Next()
+	if svcRaw == nil {
+		// Service no longer exists; nothing to duplicate onto Connect either way.
+		return false, nil
+	}
+	sn := svcRaw.(*structs.ServiceNode)
+	return sn.ServiceKind == structs.ServiceKindConnectProxy || sn.ServiceConnect.Native, nil
+}
+
 func (s *Store) serviceHealthEventsForServiceInstance(tx *txnWrapper,
 	node, serviceID string, entMeta *structs.EnterpriseMeta) ([]agentpb.Event, error) {
 
@@ -428,6 +637,10 @@ func (s *Store) serviceHealthEventsForServiceInstance(tx *txnWrapper,
 		sn.(*structs.ServiceNode), nodeChecks, svcChecks)
 }
 
+// serviceHealthEventsForServiceNodeInternal always builds the full
+// CatalogOp_Register event for the instance; it deliberately doesn't take or
+// consult a serviceHealthFilter (see the doc comment on serviceHealthFilter
+// for why that must happen at subscriber delivery, not here).
 func (s *Store) serviceHealthEventsForServiceNodeInternal(tx *txnWrapper,
 	node *structs.Node,
 	sn *structs.ServiceNode,
@@ -473,6 +686,54 @@ func (s *Store) serviceHealthEventsForServiceNodeInternal(tx *txnWrapper,
 	return []agentpb.Event{e}, nil
 }
 
+// serviceHealthCheckDeltaEvents builds the CatalogOp_UpdateCheck and
+// CatalogOp_DeleteCheck events for a service instance whose checks changed
+// but whose structs.ServiceNode record did not. Each changed check becomes
+// its own event so that subscribers only have to merge the single check into
+// their cached structs.CheckServiceNode rather than replacing it wholesale.
+// A newly created check is delivered as CatalogOp_UpdateCheck exactly like a
+// mutation of an existing one (see the CatalogOp_UpdateCheck doc comment):
+// subscribers must upsert, not replace-if-present.
+func serviceHealthCheckDeltaEvents(idx uint64, updated, deleted []*structs.HealthCheck) []agentpb.Event {
+	events := make([]agentpb.Event, 0, len(updated)+len(deleted))
+
+	for _, check := range updated {
+		var pbCheck agentpb.HealthCheck
+		pbCheck.FromStructs(check)
+
+		events = append(events, agentpb.Event{
+			Topic: agentpb.Topic_ServiceHealth,
+			Key:   check.ServiceName,
+			Index: idx,
+			Payload: &agentpb.Event_ServiceHealth{
+				ServiceHealth: &agentpb.ServiceHealthUpdate{
+					Op:    agentpb.CatalogOp_UpdateCheck,
+					Check: &pbCheck,
+				},
+			},
+		})
+	}
+
+	for _, check := range deleted {
+		var pbCheck agentpb.HealthCheck
+		pbCheck.FromStructs(check)
+
+		events = append(events, agentpb.Event{
+			Topic: agentpb.Topic_ServiceHealth,
+			Key:   check.ServiceName,
+			Index: idx,
+			Payload: &agentpb.Event_ServiceHealth{
+				ServiceHealth: &agentpb.ServiceHealthUpdate{
+					Op:    agentpb.CatalogOp_DeleteCheck,
+					Check: &pbCheck,
+				},
+			},
+		})
+	}
+
+	return events
+}
+
 func (s *Store) serviceHealthDeregEventsForServiceInstance(tx *txnWrapper,
 	sn *structs.ServiceNode, entMeta *structs.EnterpriseMeta) ([]agentpb.Event, error) {
 
@@ -0,0 +1,112 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/hashicorp/consul/agent/agentpb"
+	"github.com/stretchr/testify/require"
+)
+
+func registerEvent(topic agentpb.Topic, key, node, serviceID string) agentpb.Event {
+	return agentpb.Event{
+		Topic: topic,
+		Key:   key,
+		Payload: &agentpb.Event_ServiceHealth{
+			ServiceHealth: &agentpb.ServiceHealthUpdate{
+				Op: agentpb.CatalogOp_Register,
+				CheckServiceNode: &agentpb.CheckServiceNode{
+					Node:    &agentpb.Node{Node: node},
+					Service: &agentpb.NodeService{ID: serviceID},
+				},
+			},
+		},
+	}
+}
+
+func deregEvent(topic agentpb.Topic, key, node, serviceID string) agentpb.Event {
+	e := registerEvent(topic, key, node, serviceID)
+	e.GetServiceHealth().Op = agentpb.CatalogOp_Deregister
+	return e
+}
+
+func checkDeltaEvent(key, node, serviceID string) agentpb.Event {
+	return agentpb.Event{
+		Topic: agentpb.Topic_ServiceHealth,
+		Key:   key,
+		Payload: &agentpb.Event_ServiceHealth{
+			ServiceHealth: &agentpb.ServiceHealthUpdate{
+				Op:    agentpb.CatalogOp_UpdateCheck,
+				Check: &agentpb.HealthCheck{Node: node, ServiceID: serviceID},
+			},
+		},
+	}
+}
+
+func TestCoalesceServiceHealthEvents(t *testing.T) {
+	cases := []struct {
+		name   string
+		events []agentpb.Event
+		want   []agentpb.Event
+	}{
+		{
+			name:   "single register is untouched",
+			events: []agentpb.Event{registerEvent(agentpb.Topic_ServiceHealth, "web", "n1", "web-1")},
+			want:   []agentpb.Event{registerEvent(agentpb.Topic_ServiceHealth, "web", "n1", "web-1")},
+		},
+		{
+			name: "later register for the same instance replaces the earlier one",
+			events: []agentpb.Event{
+				registerEvent(agentpb.Topic_ServiceHealth, "web", "n1", "web-1"),
+				registerEvent(agentpb.Topic_ServiceHealth, "web", "n1", "web-1"),
+			},
+			want: []agentpb.Event{registerEvent(agentpb.Topic_ServiceHealth, "web", "n1", "web-1")},
+		},
+		{
+			name: "dereg wins over a register that arrives after it in the same transaction",
+			events: []agentpb.Event{
+				deregEvent(agentpb.Topic_ServiceHealth, "web", "n1", "web-1"),
+				registerEvent(agentpb.Topic_ServiceHealth, "web", "n1", "web-1"),
+			},
+			want: []agentpb.Event{deregEvent(agentpb.Topic_ServiceHealth, "web", "n1", "web-1")},
+		},
+		{
+			name: "register then dereg collapses to the dereg",
+			events: []agentpb.Event{
+				registerEvent(agentpb.Topic_ServiceHealth, "web", "n1", "web-1"),
+				deregEvent(agentpb.Topic_ServiceHealth, "web", "n1", "web-1"),
+			},
+			want: []agentpb.Event{deregEvent(agentpb.Topic_ServiceHealth, "web", "n1", "web-1")},
+		},
+		{
+			name: "different instances and topics are kept independently, first-seen order preserved",
+			events: []agentpb.Event{
+				registerEvent(agentpb.Topic_ServiceHealth, "web", "n1", "web-1"),
+				registerEvent(agentpb.Topic_ServiceHealth, "web", "n2", "web-2"),
+				registerEvent(agentpb.Topic_ServiceHealthConnect, "web", "n1", "web-1"),
+			},
+			want: []agentpb.Event{
+				registerEvent(agentpb.Topic_ServiceHealth, "web", "n1", "web-1"),
+				registerEvent(agentpb.Topic_ServiceHealth, "web", "n2", "web-2"),
+				registerEvent(agentpb.Topic_ServiceHealthConnect, "web", "n1", "web-1"),
+			},
+		},
+		{
+			name: "check deltas are passed through untouched and don't coalesce with registrations",
+			events: []agentpb.Event{
+				registerEvent(agentpb.Topic_ServiceHealth, "web", "n1", "web-1"),
+				checkDeltaEvent("web", "n1", "web-1"),
+			},
+			want: []agentpb.Event{
+				registerEvent(agentpb.Topic_ServiceHealth, "web", "n1", "web-1"),
+				checkDeltaEvent("web", "n1", "web-1"),
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := coalesceServiceHealthEvents(tc.events)
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
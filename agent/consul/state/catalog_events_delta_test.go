@@ -0,0 +1,113 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/hashicorp/consul/agent/agentpb"
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServiceHealthCheckDeltaEvents(t *testing.T) {
+	updated := []*structs.HealthCheck{
+		{Node: "n1", CheckID: "web-check", ServiceID: "web-1", ServiceName: "web", Status: "critical"},
+	}
+	deleted := []*structs.HealthCheck{
+		{Node: "n1", CheckID: "old-check", ServiceID: "web-1", ServiceName: "web", Status: "passing"},
+	}
+
+	events := serviceHealthCheckDeltaEvents(42, updated, deleted)
+	require.Len(t, events, 2)
+
+	require.Equal(t, agentpb.Topic_ServiceHealth, events[0].Topic)
+	require.Equal(t, "web", events[0].Key)
+	require.Equal(t, uint64(42), events[0].Index)
+	health := events[0].GetServiceHealth()
+	require.Equal(t, agentpb.CatalogOp_UpdateCheck, health.Op)
+	require.Nil(t, health.CheckServiceNode)
+	require.Equal(t, "web-check", health.Check.CheckID)
+	require.Equal(t, "critical", health.Check.Status)
+
+	health = events[1].GetServiceHealth()
+	require.Equal(t, agentpb.CatalogOp_DeleteCheck, health.Op)
+	require.Equal(t, "old-check", health.Check.CheckID)
+}
+
+func TestServiceHealthToConnectEvents(t *testing.T) {
+	regularNode := &agentpb.CheckServiceNode{
+		Node:    &agentpb.Node{Node: "n1"},
+		Service: &agentpb.NodeService{ID: "web-1", Kind: structs.ServiceKindTypical},
+	}
+	proxyNode := &agentpb.CheckServiceNode{
+		Node: &agentpb.Node{Node: "n1"},
+		Service: &agentpb.NodeService{
+			ID:   "web-proxy",
+			Kind: structs.ServiceKindConnectProxy,
+			Proxy: agentpb.ServiceProxy{
+				DestinationServiceName: "web",
+			},
+		},
+	}
+
+	cases := []struct {
+		name   string
+		events []agentpb.Event
+		want   int
+		key    string
+	}{
+		{
+			name: "plain service instance is not duplicated to Connect",
+			events: []agentpb.Event{
+				{
+					Topic: agentpb.Topic_ServiceHealth,
+					Key:   "web",
+					Payload: &agentpb.Event_ServiceHealth{
+						ServiceHealth: &agentpb.ServiceHealthUpdate{Op: agentpb.CatalogOp_Register, CheckServiceNode: regularNode},
+					},
+				},
+			},
+			want: 0,
+		},
+		{
+			name: "a check delta with no CheckServiceNode can't be duplicated to Connect",
+			events: []agentpb.Event{
+				{
+					Topic: agentpb.Topic_ServiceHealth,
+					Key:   "web",
+					Payload: &agentpb.Event_ServiceHealth{
+						ServiceHealth: &agentpb.ServiceHealthUpdate{
+							Op:    agentpb.CatalogOp_UpdateCheck,
+							Check: &agentpb.HealthCheck{ServiceID: "web-proxy"},
+						},
+					},
+				},
+			},
+			want: 0,
+		},
+		{
+			name: "a connect proxy registration is duplicated and rekeyed to its destination service",
+			events: []agentpb.Event{
+				{
+					Topic: agentpb.Topic_ServiceHealth,
+					Key:   "web-proxy",
+					Payload: &agentpb.Event_ServiceHealth{
+						ServiceHealth: &agentpb.ServiceHealthUpdate{Op: agentpb.CatalogOp_Register, CheckServiceNode: proxyNode},
+					},
+				},
+			},
+			want: 1,
+			key:  "web",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := serviceHealthToConnectEvents(tc.events)
+			require.Len(t, got, tc.want)
+			if tc.want > 0 {
+				require.Equal(t, agentpb.Topic_ServiceHealthConnect, got[0].Topic)
+				require.Equal(t, tc.key, got[0].Key)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/hashicorp/consul/agent/structs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServiceHealthFilter(t *testing.T) {
+	passing := &structs.CheckServiceNode{
+		Service: &structs.NodeService{Service: "web", Tags: []string{"canary"}},
+		Checks:  structs.HealthChecks{{Status: "passing"}},
+	}
+	critical := &structs.CheckServiceNode{
+		Service: &structs.NodeService{Service: "web"},
+		Checks:  structs.HealthChecks{{Status: "critical"}},
+	}
+
+	t.Run("empty expression matches everything", func(t *testing.T) {
+		filter, err := newServiceHealthFilter("")
+		require.NoError(t, err)
+		require.Nil(t, filter)
+
+		ok, err := filter.match(critical)
+		require.NoError(t, err)
+		require.True(t, ok)
+	})
+
+	t.Run("nil filter matches everything", func(t *testing.T) {
+		var filter *serviceHealthFilter
+		ok, err := filter.match(critical)
+		require.NoError(t, err)
+		require.True(t, ok)
+	})
+
+	t.Run("compiled expression filters on tags", func(t *testing.T) {
+		filter, err := newServiceHealthFilter(`Service.Tags contains "canary"`)
+		require.NoError(t, err)
+		require.NotNil(t, filter)
+
+		ok, err := filter.match(passing)
+		require.NoError(t, err)
+		require.True(t, ok)
+
+		ok, err = filter.match(critical)
+		require.NoError(t, err)
+		require.False(t, ok)
+	})
+
+	t.Run("compiled expression filters on check status", func(t *testing.T) {
+		filter, err := newServiceHealthFilter(`Checks.Status == "critical"`)
+		require.NoError(t, err)
+
+		ok, err := filter.match(critical)
+		require.NoError(t, err)
+		require.True(t, ok)
+
+		ok, err = filter.match(passing)
+		require.NoError(t, err)
+		require.False(t, ok)
+	})
+
+	t.Run("invalid expression fails to compile", func(t *testing.T) {
+		_, err := newServiceHealthFilter("not a valid bexpr expression")
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,102 @@
+package state
+
+import (
+	"github.com/hashicorp/consul/agent/agentpb"
+	"github.com/hashicorp/consul/agent/consul/stream"
+	"github.com/hashicorp/consul/agent/structs"
+	memdb "github.com/hashicorp/go-memdb"
+)
+
+// ConfigEntrySnapshot is a stream.SnapFn that provides a streaming snapshot
+// of agentpb.Events that describe the current state of the config-entries
+// table. A config entry's catalog identity is (Kind, Name), not Name alone,
+// so req.Key is matched against the same "Kind/Name" composite that live
+// ConfigEntryEventsFromChanges events are keyed with (see configEntryKey);
+// req.Kind, when set, further restricts the snapshot to a single Kind.
+func (s *Store) ConfigEntrySnapshot(req *agentpb.SubscribeRequest, buf *stream.EventBuffer) (uint64, error) {
+	tx := s.db.Txn(false)
+	defer tx.Abort()
+
+	idx, iter, err := s.configEntriesTxn(tx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	for raw := iter.Next(); raw != nil; raw = iter.Next() {
+		entry := raw.(structs.ConfigEntry)
+		if req.Kind != "" && entry.GetKind() != req.Kind {
+			continue
+		}
+		if req.Key != "" && configEntryKey(entry) != req.Key {
+			continue
+		}
+		event, err := configEntryToEvent(idx, agentpb.CatalogOp_Register, entry)
+		if err != nil {
+			return 0, err
+		}
+		buf.Append([]agentpb.Event{event})
+	}
+
+	return idx, nil
+}
+
+// configEntryKey builds the agentpb.Event Key for a config entry. Kind and
+// Name together are a config entry's real catalog identity; keying on Name
+// alone would collide two same-named entries of different Kind (e.g. a
+// service-defaults and a service-resolver both named "web") onto the same
+// topic key.
+func configEntryKey(entry structs.ConfigEntry) string {
+	return entry.GetKind() + "/" + entry.GetName()
+}
+
+// configEntryToEvent converts a structs.ConfigEntry into the agentpb.Event
+// that should be delivered to config-entries topic subscribers for it.
+func configEntryToEvent(idx uint64, op agentpb.CatalogOp, entry structs.ConfigEntry) (agentpb.Event, error) {
+	var pbEntry agentpb.ConfigEntry
+	if err := pbEntry.FromStructs(entry); err != nil {
+		return agentpb.Event{}, err
+	}
+
+	return agentpb.Event{
+		Topic: agentpb.Topic_ConfigEntry,
+		Key:   configEntryKey(entry),
+		Index: idx,
+		Payload: &agentpb.Event_ConfigEntryOp{
+			ConfigEntryOp: &agentpb.ConfigEntryUpdate{
+				Op:    op,
+				Entry: &pbEntry,
+			},
+		},
+	}, nil
+}
+
+// ConfigEntryEventsFromChanges returns all the config-entries topic events
+// that should be emitted given a set of changes to the state store.
+func (s *Store) ConfigEntryEventsFromChanges(tx *txnWrapper, changes memdb.Changes) ([]agentpb.Event, error) {
+	var events []agentpb.Event
+
+	for _, change := range changes {
+		if change.Table != "config-entries" {
+			continue
+		}
+
+		if change.Deleted() {
+			entry := change.Before.(structs.ConfigEntry)
+			event, err := configEntryToEvent(tx.Index, agentpb.CatalogOp_Deregister, entry)
+			if err != nil {
+				return nil, err
+			}
+			events = append(events, event)
+			continue
+		}
+
+		entry := change.After.(structs.ConfigEntry)
+		event, err := configEntryToEvent(tx.Index, agentpb.CatalogOp_Register, entry)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	return events, nil
+}
@@ -0,0 +1,80 @@
+package state
+
+import (
+	"github.com/hashicorp/consul/agent/agentpb"
+	"github.com/hashicorp/consul/agent/consul/stream"
+	"github.com/hashicorp/consul/agent/structs"
+	memdb "github.com/hashicorp/go-memdb"
+)
+
+// KVSnapshot is a stream.SnapFn that provides a streaming snapshot of
+// agentpb.Events that describe the current state of the KV store matching
+// the subscription request. If req.KeyPrefix is set, Key is treated as a
+// prefix and all entries nested under it are included; otherwise Key must be
+// an exact match.
+func (s *Store) KVSnapshot(req *agentpb.SubscribeRequest, buf *stream.EventBuffer) (uint64, error) {
+	tx := s.db.Txn(false)
+	defer tx.Abort()
+
+	if req.KeyPrefix {
+		idx, entries, err := s.kvsListTxn(tx, nil, req.Key)
+		if err != nil {
+			return 0, err
+		}
+		for _, entry := range entries {
+			buf.Append([]agentpb.Event{kvEntryToEvent(idx, agentpb.CatalogOp_Register, entry)})
+		}
+		return idx, nil
+	}
+
+	idx, entry, err := s.kvsGetTxn(tx, nil, req.Key)
+	if err != nil {
+		return 0, err
+	}
+	if entry != nil {
+		buf.Append([]agentpb.Event{kvEntryToEvent(idx, agentpb.CatalogOp_Register, entry)})
+	}
+	return idx, nil
+}
+
+// kvEntryToEvent converts a structs.DirEntry into the agentpb.Event that
+// should be delivered to KV topic subscribers for it.
+func kvEntryToEvent(idx uint64, op agentpb.CatalogOp, entry *structs.DirEntry) agentpb.Event {
+	var pbEntry agentpb.KVSEntry
+	pbEntry.FromStructs(entry)
+
+	return agentpb.Event{
+		Topic: agentpb.Topic_KV,
+		Key:   entry.Key,
+		Index: idx,
+		Payload: &agentpb.Event_KVSOp{
+			KVSOp: &agentpb.KVSOpUpdate{
+				Op:    op,
+				Entry: &pbEntry,
+			},
+		},
+	}
+}
+
+// KVEventsFromChanges returns all the KV events that should be emitted given
+// a set of changes to the state store.
+func (s *Store) KVEventsFromChanges(tx *txnWrapper, changes memdb.Changes) ([]agentpb.Event, error) {
+	var events []agentpb.Event
+
+	for _, change := range changes {
+		if change.Table != "kvs" {
+			continue
+		}
+
+		if change.Deleted() {
+			entry := change.Before.(*structs.DirEntry)
+			events = append(events, kvEntryToEvent(tx.Index, agentpb.CatalogOp_Deregister, entry))
+			continue
+		}
+
+		entry := change.After.(*structs.DirEntry)
+		events = append(events, kvEntryToEvent(tx.Index, agentpb.CatalogOp_Register, entry))
+	}
+
+	return events, nil
+}
@@ -0,0 +1,73 @@
+package state
+
+import (
+	"github.com/hashicorp/consul/agent/agentpb"
+	"github.com/hashicorp/consul/agent/consul/stream"
+	"github.com/hashicorp/consul/agent/structs"
+	memdb "github.com/hashicorp/go-memdb"
+)
+
+// NodeSnapshot is a stream.SnapFn that provides a streaming snapshot of
+// agentpb.Events that describe the current state of the catalog's nodes.
+// Unlike ServiceHealthSnapshot this is not scoped to a single service; it
+// describes node registrations themselves rather than the services on them.
+func (s *Store) NodeSnapshot(req *agentpb.SubscribeRequest, buf *stream.EventBuffer) (uint64, error) {
+	tx := s.db.Txn(false)
+	defer tx.Abort()
+
+	idx, iter, err := s.nodesTxn(tx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	for raw := iter.Next(); raw != nil; raw = iter.Next() {
+		n := raw.(*structs.Node)
+		buf.Append([]agentpb.Event{nodeToEvent(idx, agentpb.CatalogOp_Register, n)})
+	}
+
+	return idx, nil
+}
+
+// nodeToEvent converts a structs.Node into the agentpb.Event that should be
+// delivered to Node topic subscribers for it.
+func nodeToEvent(idx uint64, op agentpb.CatalogOp, n *structs.Node) agentpb.Event {
+	var pbNode agentpb.Node
+	pbNode.FromStructs(n)
+
+	return agentpb.Event{
+		Topic: agentpb.Topic_Node,
+		Key:   n.Node,
+		Index: idx,
+		Payload: &agentpb.Event_NodeOp{
+			NodeOp: &agentpb.NodeUpdate{
+				Op:   op,
+				Node: &pbNode,
+			},
+		},
+	}
+}
+
+// NodeEventsFromChanges returns all the Node topic events that should be
+// emitted given a set of changes to the state store. This only concerns
+// itself with the "nodes" table; service and check mutations are reported on
+// the ServiceHealth topic by ServiceHealthEventsFromChanges.
+func (s *Store) NodeEventsFromChanges(tx *txnWrapper, changes memdb.Changes) ([]agentpb.Event, error) {
+	var events []agentpb.Event
+
+	for _, change := range changes {
+		if change.Table != "nodes" {
+			continue
+		}
+
+		if change.Deleted() {
+			n := change.Before.(*structs.Node)
+			events = append(events, nodeToEvent(tx.Index, agentpb.CatalogOp_Deregister, n))
+			continue
+		}
+
+		n := change.After.(*structs.Node)
+		events = append(events, nodeToEvent(tx.Index, agentpb.CatalogOp_Register, n))
+	}
+
+	return events, nil
+}